@@ -2,23 +2,32 @@ package cmd
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"runtime/trace"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/ext"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/microsoft/go-mssqldb"
 	"github.com/spf13/cobra"
 	"google.golang.org/protobuf/encoding/protojson"
 
@@ -38,14 +47,22 @@ const RuleDbPrepare = "sqlc/db-prepare"
 const QueryFlagSqlcVetDisable = "@sqlc-vet-disable"
 
 func NewCmdVet() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "vet",
 		Short: "Vet examines queries",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			defer trace.StartRegion(cmd.Context(), "vet").End()
 			stderr := cmd.ErrOrStderr()
 			dir, name := getConfigPath(stderr, cmd.Flag("file"))
-			if err := Vet(cmd.Context(), ParseEnv(cmd), dir, name, stderr); err != nil {
+			e := ParseEnv(cmd)
+			e.Offline, _ = cmd.Flags().GetBool("offline")
+			e.Record, _ = cmd.Flags().GetBool("record")
+			if e.Offline && e.Record {
+				fmt.Fprintf(stderr, "only one of --offline or --record may be set\n")
+				os.Exit(1)
+			}
+			e.Jobs, _ = cmd.Flags().GetInt("jobs")
+			if err := Vet(cmd.Context(), e, dir, name, stderr); err != nil {
 				if !errors.Is(err, ErrFailedChecks) {
 					fmt.Fprintf(stderr, "%s\n", err)
 				}
@@ -54,6 +71,10 @@ func NewCmdVet() *cobra.Command {
 			return nil
 		},
 	}
+	cmd.Flags().Bool("record", false, "populate the offline vet cache (.sqlc/vet-cache) from live EXPLAIN output")
+	cmd.Flags().Bool("offline", false, "vet using the offline vet cache instead of a live database connection")
+	cmd.Flags().Int("jobs", runtime.GOMAXPROCS(0), "number of queries to vet concurrently")
+	return cmd
 }
 
 func Vet(ctx context.Context, e Env, dir, filename string, stderr io.Writer) error {
@@ -81,6 +102,8 @@ func Vet(ctx context.Context, e Env, dir, filename string, stderr io.Writer) err
 			&plugin.VetQuery{},
 			&plugin.PostgreSQLExplain{},
 			&plugin.MySQLExplain{},
+			&plugin.MSSQLExplain{},
+			&plugin.QueryStats{},
 		),
 		cel.Variable("query",
 			cel.ObjectType("plugin.VetQuery"),
@@ -94,6 +117,12 @@ func Vet(ctx context.Context, e Env, dir, filename string, stderr io.Writer) err
 		cel.Variable("mysql",
 			cel.ObjectType("plugin.MySQL"),
 		),
+		cel.Variable("mssql",
+			cel.ObjectType("plugin.MSSQL"),
+		),
+		cel.Variable("stats",
+			cel.ObjectType("plugin.QueryStats"),
+		),
 	)
 	if err != nil {
 		return fmt.Errorf("new CEL env error: %s", err)
@@ -126,9 +155,13 @@ func Vet(ctx context.Context, e Env, dir, filename string, stderr io.Writer) err
 		// TODO There's probably a nicer way to do this from the ast
 		// https://pkg.go.dev/github.com/google/cel-go/common/ast#AllMatcher
 		if strings.Contains(c.Rule, "postgresql.explain") ||
-			strings.Contains(c.Rule, "mysql.explain") {
+			strings.Contains(c.Rule, "mysql.explain") ||
+			strings.Contains(c.Rule, "mssql.explain") {
 			rule.NeedsExplain = true
 		}
+		if strings.Contains(c.Rule, "stats.") {
+			rule.NeedsStats = true
+		}
 
 		rules[c.Name] = rule
 	}
@@ -141,6 +174,9 @@ func Vet(ctx context.Context, e Env, dir, filename string, stderr io.Writer) err
 		Envmap:     map[string]string{},
 		Stderr:     stderr,
 		NoDatabase: e.NoDatabase,
+		Offline:    e.Offline,
+		Record:     e.Record,
+		Jobs:       e.Jobs,
 	}
 	errored := false
 	for _, sql := range conf.SQL {
@@ -183,6 +219,11 @@ func prepareable(sql config.SQL, raw *ast.RawStmt) bool {
 	if sql.Engine == config.EngineSQLite {
 		return true
 	}
+	// T-SQL's sp_prepare can handle nearly any statement shape, so treat
+	// MSSQL the same as MySQL.
+	if sql.Engine == config.EngineMSSQL {
+		return true
+	}
 	return false
 }
 
@@ -190,19 +231,26 @@ type preparer interface {
 	Prepare(context.Context, string, string) error
 }
 
+// pgxConn vets queries against a pgxpool.Pool rather than a single
+// connection, so that concurrent workers each get their own backend.
 type pgxConn struct {
-	c *pgx.Conn
+	pool *pgxpool.Pool
 }
 
 func (p *pgxConn) Prepare(ctx context.Context, name, query string) error {
-	_, err := p.c.Prepare(ctx, name, query)
+	conn, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	_, err = conn.Conn().Prepare(ctx, name, query)
 	return err
 }
 
 func (p *pgxConn) Explain(ctx context.Context, query string, args ...*plugin.Parameter) (*vetEngineOutput, error) {
-	eQuery := "EXPLAIN (ANALYZE false, VERBOSE, COSTS, SETTINGS, BUFFERS, FORMAT JSON) "+query
+	eQuery := "EXPLAIN (ANALYZE false, VERBOSE, COSTS, SETTINGS, BUFFERS, FORMAT JSON) " + query
 	eArgs := make([]any, len(args))
-	row := p.c.QueryRow(ctx, eQuery, eArgs...)
+	row := p.pool.QueryRow(ctx, eQuery, eArgs...)
 	var result []json.RawMessage
 	if err := row.Scan(&result); err != nil {
 		return nil, err
@@ -218,26 +266,178 @@ func (p *pgxConn) Explain(ctx context.Context, query string, args ...*plugin.Par
 	return &vetEngineOutput{PostgreSQL: &plugin.PostgreSQL{Explain: &explain}}, nil
 }
 
+func (p *pgxConn) EngineVersion(ctx context.Context) (string, error) {
+	var version string
+	if err := p.pool.QueryRow(ctx, "SELECT version()").Scan(&version); err != nil {
+		return "", err
+	}
+	return version, nil
+}
+
 type dbPreparer struct {
 	db *sql.DB
 }
 
 func (p *dbPreparer) Prepare(ctx context.Context, name, query string) error {
 	s, err := p.db.PrepareContext(ctx, query)
-	s.Close()
-	return err
+	if err != nil {
+		// Some drivers, such as go-mssqldb, return a nil statement
+		// alongside the error.
+		return err
+	}
+	return s.Close()
 }
 
 type explainer interface {
 	Explain(context.Context, string, ...*plugin.Parameter) (*vetEngineOutput, error)
 }
 
+// statser looks up historical performance signals for a normalized query,
+// such as pg_stat_statements or MySQL's performance_schema, for rules that
+// want to fail on queries already known to be slow elsewhere.
+type statser interface {
+	Stats(ctx context.Context, query string) (*plugin.QueryStats, error)
+}
+
+// pgStatStatementsStatser serves stats.* rules from a single, up-front
+// snapshot of pg_stat_statements, keyed by normalized query text. Without
+// this, a worker pool vetting hundreds of queries would issue hundreds of
+// full scans of what's often a large, shared, prod-readonly table.
+type pgStatStatementsStatser struct {
+	stats map[string]*plugin.QueryStats
+}
+
+func newPgStatStatementsStatser(ctx context.Context, pool *pgxpool.Pool) (*pgStatStatementsStatser, error) {
+	const statsQuery = `
+SELECT query, mean_exec_time, calls, rows, shared_blks_hit, shared_blks_read
+FROM pg_stat_statements
+`
+	rows, err := pool.Query(ctx, statsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("pg_stat_statements: %w", err)
+	}
+	defer rows.Close()
+
+	stats := map[string]*plugin.QueryStats{}
+	for rows.Next() {
+		var text string
+		var meanExecTimeMs, rowCount, blksHit, blksRead float64
+		var calls int64
+		if err := rows.Scan(&text, &meanExecTimeMs, &calls, &rowCount, &blksHit, &blksRead); err != nil {
+			return nil, fmt.Errorf("pg_stat_statements: %w", err)
+		}
+		norm := normalizeQuery(text)
+		if _, ok := stats[norm]; ok {
+			// Keep the first entry pg_stat_statements returns for a given
+			// normalized text; later rows are typically the same query
+			// under a different plan, which we don't distinguish here.
+			continue
+		}
+		qs := &plugin.QueryStats{
+			MeanExecTimeMs: meanExecTimeMs,
+			Calls:          calls,
+		}
+		if calls > 0 {
+			qs.RowsPerCall = rowCount / float64(calls)
+		}
+		if blksHit+blksRead > 0 {
+			qs.SharedBlksHitRatio = blksHit / (blksHit + blksRead)
+		}
+		stats[norm] = qs
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("pg_stat_statements: %w", err)
+	}
+	return &pgStatStatementsStatser{stats: stats}, nil
+}
+
+// normalizeQuery approximates the normalization pg_stat_statements applies
+// to its `query` column (literal constants folded away) and MySQL's
+// performance_schema applies to `DIGEST_TEXT` (literals folded, keywords
+// uppercased, whitespace collapsed): lowercase, blank out string and
+// numeric literals, and collapse whitespace. It's not a byte-for-byte
+// match of either engine's real normalizer, but it's enough to compare a
+// query as written in a .sql file against its recorded, as-executed form.
+func normalizeQuery(query string) string {
+	q := strings.ToLower(query)
+	q = reStringLiteral.ReplaceAllString(q, "?")
+	q = reNumberLiteral.ReplaceAllString(q, "?")
+	q = reWhitespace.ReplaceAllString(q, " ")
+	return strings.TrimSpace(q)
+}
+
+var (
+	reStringLiteral = regexp.MustCompile(`'(?:[^']|'')*'`)
+	reNumberLiteral = regexp.MustCompile(`\b\d+\b`)
+	reWhitespace    = regexp.MustCompile(`\s+`)
+)
+
+func (p *pgStatStatementsStatser) Stats(ctx context.Context, query string) (*plugin.QueryStats, error) {
+	if qs, ok := p.stats[normalizeQuery(query)]; ok {
+		return qs, nil
+	}
+	return nil, fmt.Errorf("pg_stat_statements: no entry matches this query's normalized form")
+}
+
+// mysqlPerfSchemaStatser serves stats.* rules from a single, up-front
+// snapshot of performance_schema.events_statements_summary_by_digest, keyed
+// by normalized query text, for the same reason pgStatStatementsStatser
+// does: one full scan per checkSQL call instead of one per query.
+type mysqlPerfSchemaStatser struct {
+	stats map[string]*plugin.QueryStats
+}
+
+func newMysqlPerfSchemaStatser(ctx context.Context, db *sql.DB) (*mysqlPerfSchemaStatser, error) {
+	const statsQuery = `
+SELECT DIGEST_TEXT, AVG_TIMER_WAIT / 1000000000, COUNT_STAR, SUM_ROWS_SENT / COUNT_STAR
+FROM performance_schema.events_statements_summary_by_digest
+`
+	rows, err := db.QueryContext(ctx, statsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("performance_schema: %w", err)
+	}
+	defer rows.Close()
+
+	stats := map[string]*plugin.QueryStats{}
+	for rows.Next() {
+		var text sql.NullString
+		var meanExecTimeMs, rowsPerCall float64
+		var calls int64
+		if err := rows.Scan(&text, &meanExecTimeMs, &calls, &rowsPerCall); err != nil {
+			return nil, fmt.Errorf("performance_schema: %w", err)
+		}
+		if !text.Valid {
+			continue
+		}
+		norm := normalizeQuery(text.String)
+		if _, ok := stats[norm]; ok {
+			continue
+		}
+		stats[norm] = &plugin.QueryStats{
+			MeanExecTimeMs: meanExecTimeMs,
+			Calls:          calls,
+			RowsPerCall:    rowsPerCall,
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("performance_schema: %w", err)
+	}
+	return &mysqlPerfSchemaStatser{stats: stats}, nil
+}
+
+func (m *mysqlPerfSchemaStatser) Stats(ctx context.Context, query string) (*plugin.QueryStats, error) {
+	if qs, ok := m.stats[normalizeQuery(query)]; ok {
+		return qs, nil
+	}
+	return nil, fmt.Errorf("performance_schema: no entry matches this query's normalized form")
+}
+
 type mysqlExplainer struct {
 	*sql.DB
 }
 
 func (me *mysqlExplainer) Explain(ctx context.Context, query string, args ...*plugin.Parameter) (*vetEngineOutput, error) {
-	eQuery := "EXPLAIN FORMAT=JSON "+query
+	eQuery := "EXPLAIN FORMAT=JSON " + query
 	eArgs := make([]any, len(args))
 	row := me.QueryRowContext(ctx, eQuery, eArgs...)
 	var result json.RawMessage
@@ -258,11 +458,63 @@ func (me *mysqlExplainer) Explain(ctx context.Context, query string, args ...*pl
 	return &vetEngineOutput{MySQL: &plugin.MySQL{Explain: &explain}}, nil
 }
 
+func (me *mysqlExplainer) EngineVersion(ctx context.Context) (string, error) {
+	var version string
+	if err := me.QueryRowContext(ctx, "SELECT VERSION()").Scan(&version); err != nil {
+		return "", err
+	}
+	return version, nil
+}
+
+type mssqlExplainer struct {
+	*sql.DB
+}
+
+// Explain runs the query with SET SHOWPLAN_XML ON, which asks SQL Server to
+// return the estimated execution plan as XML instead of running the query.
+func (me *mssqlExplainer) Explain(ctx context.Context, query string, args ...*plugin.Parameter) (*vetEngineOutput, error) {
+	conn, err := me.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SET SHOWPLAN_XML ON"); err != nil {
+		return nil, fmt.Errorf("mssql: enabling SHOWPLAN_XML: %w", err)
+	}
+	defer conn.ExecContext(ctx, "SET SHOWPLAN_XML OFF")
+
+	eArgs := make([]any, len(args))
+	row := conn.QueryRowContext(ctx, query, eArgs...)
+	var result string
+	if err := row.Scan(&result); err != nil {
+		return nil, err
+	}
+	if debug.Debug.DumpExplain {
+		fmt.Println(query)
+		fmt.Println(result)
+	}
+	var explain plugin.MSSQLExplain
+	if err := xml.Unmarshal([]byte(result), &explain); err != nil {
+		return nil, err
+	}
+	return &vetEngineOutput{MSSQL: &plugin.MSSQL{Explain: &explain}}, nil
+}
+
+func (me *mssqlExplainer) EngineVersion(ctx context.Context) (string, error) {
+	var version string
+	if err := me.QueryRowContext(ctx, "SELECT @@VERSION").Scan(&version); err != nil {
+		return "", err
+	}
+	return version, nil
+}
+
 type rule struct {
 	Program      *cel.Program
 	Message      string
 	NeedsPrepare bool
 	NeedsExplain bool
+	NeedsStats   bool
 }
 
 type checker struct {
@@ -273,6 +525,16 @@ type checker struct {
 	Envmap     map[string]string
 	Stderr     io.Writer
 	NoDatabase bool
+	// Offline serves EXPLAIN output entirely from the on-disk vet cache,
+	// without opening any live database connection (so rules that need
+	// to prepare a query fail for lack of one).
+	Offline bool
+	// Record populates the on-disk vet cache from live EXPLAIN output
+	// instead of reading from it.
+	Record bool
+	// Jobs is the number of queries to vet concurrently. A value less
+	// than 1 falls back to runtime.GOMAXPROCS(0).
+	Jobs int
 }
 
 func (c *checker) DSN(dsn string) (string, error) {
@@ -303,6 +565,16 @@ func (c *checker) checkSQL(ctx context.Context, s config.SQL) error {
 	}
 	s.Queries = joined
 
+	if s.Database != nil && s.Database.Migrations != "" {
+		// s.Database is a pointer shared with the caller's *config.Config,
+		// so copy it before joining in c.Dir -- writing through the pointer
+		// would leave the shared config permanently rewritten and break a
+		// second vet of the same parsed config.
+		database := *s.Database
+		database.Migrations = filepath.Join(c.Dir, database.Migrations)
+		s.Database = &database
+	}
+
 	var name string
 	parseOpts := opts.Parser{
 		Debug: debug.Debug,
@@ -315,14 +587,24 @@ func (c *checker) checkSQL(ctx context.Context, s config.SQL) error {
 
 	var prep preparer
 	var expl explainer
-	if s.Database != nil { // TODO only set up a database connection if a rule evaluation requires it
-		if c.NoDatabase {
-			return fmt.Errorf("database: connections disabled via command line flag")
-		}
+	var stats statser
+	// NoDatabase is treated the same as Offline below: no live connection is
+	// opened, and explain output is served from the on-disk vet cache
+	// instead, failing a given query only if no cache entry covers it.
+	offline := c.Offline || c.NoDatabase
+	haveDatabase := s.Database != nil && !offline
+	if haveDatabase { // TODO only set up a database connection if a rule evaluation requires it
 		dburl, err := c.DSN(s.Database.URI)
 		if err != nil {
 			return err
 		}
+		managed := s.Database.Managed || s.Database.Migrations != ""
+
+		jobs := c.Jobs
+		if jobs < 1 {
+			jobs = runtime.GOMAXPROCS(0)
+		}
+
 		switch s.Engine {
 		case config.EnginePostgreSQL:
 			conn, err := pgx.Connect(ctx, dburl)
@@ -330,13 +612,50 @@ func (c *checker) checkSQL(ctx context.Context, s config.SQL) error {
 				return fmt.Errorf("database: connection error: %s", err)
 			}
 			if err := conn.Ping(ctx); err != nil {
+				conn.Close(ctx)
 				return fmt.Errorf("database: connection error: %s", err)
 			}
 			defer conn.Close(ctx)
-			pConn := &pgxConn{conn}
+			var schemaName string
+			if managed {
+				name, cleanup, err := managePostgresSchema(ctx, conn, s)
+				if err != nil {
+					return err
+				}
+				schemaName = name
+				// conn stays open for the lifetime of checkSQL so this
+				// cleanup can still reach the database once vetting is done.
+				defer cleanup(ctx)
+			}
+
+			poolConfig, err := pgxpool.ParseConfig(dburl)
+			if err != nil {
+				return fmt.Errorf("database: connection error: %s", err)
+			}
+			poolConfig.MaxConns = int32(jobs)
+			if schemaName != "" {
+				// Keep "public" on the search_path behind the managed
+				// schema so extensions installed there (pg_stat_statements,
+				// in particular) are still visible to stats.* rules.
+				poolConfig.ConnConfig.RuntimeParams["search_path"] = schemaName + ", public"
+			}
+			pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+			if err != nil {
+				return fmt.Errorf("database: connection error: %s", err)
+			}
+			defer pool.Close()
+			pConn := &pgxConn{pool}
 			prep = pConn
 			expl = pConn
 		case config.EngineMySQL:
+			if managed {
+				managedURL, cleanup, err := manageMySQLDatabase(ctx, dburl, s)
+				if err != nil {
+					return err
+				}
+				defer cleanup(ctx)
+				dburl = managedURL
+			}
 			db, err := sql.Open("mysql", dburl)
 			if err != nil {
 				return fmt.Errorf("database: connection error: %s", err)
@@ -345,9 +664,23 @@ func (c *checker) checkSQL(ctx context.Context, s config.SQL) error {
 				return fmt.Errorf("database: connection error: %s", err)
 			}
 			defer db.Close()
+			db.SetMaxOpenConns(jobs)
+			if managed {
+				if err := applySchemaDB(ctx, db, s); err != nil {
+					return err
+				}
+			}
 			prep = &dbPreparer{db}
 			expl = &mysqlExplainer{db}
 		case config.EngineSQLite:
+			if managed {
+				managedURL, cleanup, err := manageSQLiteFile()
+				if err != nil {
+					return err
+				}
+				defer cleanup()
+				dburl = managedURL
+			}
 			db, err := sql.Open("sqlite3", dburl)
 			if err != nil {
 				return fmt.Errorf("database: connection error: %s", err)
@@ -356,26 +689,137 @@ func (c *checker) checkSQL(ctx context.Context, s config.SQL) error {
 				return fmt.Errorf("database: connection error: %s", err)
 			}
 			defer db.Close()
+			// mattn/go-sqlite3 serializes access to a single file anyway, so
+			// don't hand out more connections than the worker pool can use
+			// concurrently without just blocking on each other.
+			db.SetMaxOpenConns(1)
+			if managed {
+				if err := applySchemaDB(ctx, db, s); err != nil {
+					return err
+				}
+			}
 			prep = &dbPreparer{db}
 			// SQLite really doesn't want us to depend on the output of EXPLAIN
 			// QUERY PLAN: https://www.sqlite.org/eqp.html
 			expl = nil
+		case config.EngineMSSQL:
+			db, err := sql.Open("sqlserver", dburl)
+			if err != nil {
+				return fmt.Errorf("database: connection error: %s", err)
+			}
+			if err := db.PingContext(ctx); err != nil {
+				return fmt.Errorf("database: connection error: %s", err)
+			}
+			defer db.Close()
+			db.SetMaxOpenConns(jobs)
+			prep = &dbPreparer{db}
+			expl = &mssqlExplainer{db}
 		default:
 			return fmt.Errorf("unsupported database uri: %s", s.Engine)
 		}
+
+		needsStats := false
+		for _, name := range s.Rules {
+			if rule, ok := c.Rules[name]; ok && rule.NeedsStats {
+				needsStats = true
+				break
+			}
+		}
+
+		if needsStats {
+			statsURL := dburl
+			if s.Database.StatsDSN != "" {
+				u, err := c.DSN(s.Database.StatsDSN)
+				if err != nil {
+					return err
+				}
+				statsURL = u
+			}
+			switch s.Engine {
+			case config.EnginePostgreSQL:
+				if s.Database.StatsDSN != "" {
+					statsPool, err := pgxpool.New(ctx, statsURL)
+					if err != nil {
+						return fmt.Errorf("database: stats connection error: %s", err)
+					}
+					defer statsPool.Close()
+					st, err := newPgStatStatementsStatser(ctx, statsPool)
+					if err != nil {
+						return err
+					}
+					stats = st
+				} else if pConn, ok := expl.(*pgxConn); ok {
+					st, err := newPgStatStatementsStatser(ctx, pConn.pool)
+					if err != nil {
+						return err
+					}
+					stats = st
+				}
+			case config.EngineMySQL:
+				if s.Database.StatsDSN != "" {
+					statsDB, err := sql.Open("mysql", statsURL)
+					if err != nil {
+						return fmt.Errorf("database: stats connection error: %s", err)
+					}
+					defer statsDB.Close()
+					st, err := newMysqlPerfSchemaStatser(ctx, statsDB)
+					if err != nil {
+						return err
+					}
+					stats = st
+				} else if db, ok := prep.(*dbPreparer); ok {
+					st, err := newMysqlPerfSchemaStatser(ctx, db.db)
+					if err != nil {
+						return err
+					}
+					stats = st
+				}
+			}
+		}
+	}
+
+	if offline || c.Record {
+		if expl == nil && !offline {
+			return fmt.Errorf("vet cache: --record requires a database connection")
+		}
+		var engineVersion string
+		if ve, ok := expl.(versionedExplainer); ok {
+			v, err := ve.EngineVersion(ctx)
+			if err != nil {
+				return fmt.Errorf("vet cache: reading engine version: %w", err)
+			}
+			engineVersion = v
+		}
+		fingerprint, err := schemaFingerprint(s)
+		if err != nil {
+			return fmt.Errorf("vet cache: fingerprinting schema: %w", err)
+		}
+		expl = &cachingExplainer{
+			next:              expl,
+			engine:            string(s.Engine),
+			dir:               filepath.Join(c.Dir, ".sqlc", "vet-cache"),
+			record:            c.Record,
+			engineVersion:     engineVersion,
+			schemaFingerprint: fingerprint,
+		}
 	}
 
-	errored := false
 	req := codeGenRequest(result, combo)
 	cfg := vetConfig(req)
-	for i, query := range req.Queries {
+
+	// checkQuery evaluates every configured rule for req.Queries[i],
+	// writing diagnostics to out instead of c.Stderr directly so they can
+	// be printed back in source order once every worker has finished.
+	checkQuery := func(i, worker int, out io.Writer) (bool, error) {
+		query := req.Queries[i]
 		if result.Queries[i].Flags[QueryFlagSqlcVetDisable] {
 			if debug.Active {
 				log.Printf("Skipping vet rules for query: %s\n", query.Name)
 			}
-			continue
+			return false, nil
 		}
 
+		failed := false
 		evalMap := map[string]any{
 			"query":  vetQuery(query),
 			"config": cfg,
@@ -384,24 +828,24 @@ func (c *checker) checkSQL(ctx context.Context, s config.SQL) error {
 		for _, name := range s.Rules {
 			rule, ok := c.Rules[name]
 			if !ok {
-				return fmt.Errorf("type-check error: a rule with the name '%s' does not exist", name)
+				return false, fmt.Errorf("type-check error: a rule with the name '%s' does not exist", name)
 			}
 
 			if rule.NeedsPrepare {
 				if prep == nil {
-					fmt.Fprintf(c.Stderr, "%s: %s: %s: error preparing query: database connection required\n", query.Filename, query.Name, name)
-					errored = true
+					fmt.Fprintf(out, "%s: %s: %s: error preparing query: database connection required\n", query.Filename, query.Name, name)
+					failed = true
 					continue
 				}
 				if !prepareable(s, result.Queries[i].RawStmt) {
-					fmt.Fprintf(c.Stderr, "%s: %s: %s: error preparing query: %s\n", query.Filename, query.Name, name, "query type is unpreparable")
-					errored = true
+					fmt.Fprintf(out, "%s: %s: %s: error preparing query: %s\n", query.Filename, query.Name, name, "query type is unpreparable")
+					failed = true
 					continue
 				}
-				name := fmt.Sprintf("sqlc_vet_%d_%d", time.Now().Unix(), i)
-				if err := prep.Prepare(ctx, name, query.Text); err != nil {
-					fmt.Fprintf(c.Stderr, "%s: %s: %s: error preparing query: %s\n", query.Filename, query.Name, name, err)
-					errored = true
+				stmtName := fmt.Sprintf("sqlc_vet_%d_%d_%d", os.Getpid(), worker, i)
+				if err := prep.Prepare(ctx, stmtName, query.Text); err != nil {
+					fmt.Fprintf(out, "%s: %s: %s: error preparing query: %s\n", query.Filename, query.Name, name, err)
+					failed = true
 					continue
 				}
 			}
@@ -412,42 +856,100 @@ func (c *checker) checkSQL(ctx context.Context, s config.SQL) error {
 			}
 
 			// Get explain output for this query if we need it
-			_, pgsqlOK := evalMap["postgresql"]; _, mysqlOK := evalMap["mysql"]
-			if rule.NeedsExplain && !(pgsqlOK || mysqlOK) {
+			_, pgsqlOK := evalMap["postgresql"]
+			_, mysqlOK := evalMap["mysql"]
+			_, mssqlOK := evalMap["mssql"]
+			if rule.NeedsExplain && !(pgsqlOK || mysqlOK || mssqlOK) {
 				if expl == nil {
-					fmt.Fprintf(c.Stderr, "%s: %s: %s: error explaining query: database connection required\n", query.Filename, query.Name, name)
-					errored = true
+					fmt.Fprintf(out, "%s: %s: %s: error explaining query: database connection required\n", query.Filename, query.Name, name)
+					failed = true
 					continue
 				}
 				engineOutput, err := expl.Explain(ctx, query.Text, query.Params...)
 				if err != nil {
-					fmt.Fprintf(c.Stderr, "%s: %s: %s: error explaining query: %s\n", query.Filename, query.Name, name, err)
-					errored = true
+					fmt.Fprintf(out, "%s: %s: %s: error explaining query: %s\n", query.Filename, query.Name, name, err)
+					failed = true
 					continue
 				}
 
 				evalMap["postgresql"] = engineOutput.PostgreSQL
 				evalMap["mysql"] = engineOutput.MySQL
+				evalMap["mssql"] = engineOutput.MSSQL
+			}
+
+			if _, ok := evalMap["stats"]; rule.NeedsStats && !ok {
+				if stats == nil {
+					fmt.Fprintf(out, "%s: %s: %s: error fetching query stats: database connection required\n", query.Filename, query.Name, name)
+					failed = true
+					continue
+				}
+				queryStats, err := stats.Stats(ctx, query.Text)
+				if err != nil {
+					fmt.Fprintf(out, "%s: %s: %s: error fetching query stats: %s\n", query.Filename, query.Name, name, err)
+					failed = true
+					continue
+				}
+				evalMap["stats"] = queryStats
 			}
 
-			out, _, err := (*rule.Program).Eval(evalMap)
+			// cel.Program.Eval is safe to call concurrently as long as each
+			// call gets its own evalMap, which it does here.
+			out2, _, err := (*rule.Program).Eval(evalMap)
 			if err != nil {
-				return err
+				return false, err
 			}
-			tripped, ok := out.Value().(bool)
+			tripped, ok := out2.Value().(bool)
 			if !ok {
-				return fmt.Errorf("expression returned non-bool value: %v", out.Value())
+				return false, fmt.Errorf("expression returned non-bool value: %v", out2.Value())
 			}
 			if tripped {
 				// TODO: Get line numbers in the output
 				if rule.Message == "" {
-					fmt.Fprintf(c.Stderr, "%s: %s: %s\n", query.Filename, query.Name, name)
+					fmt.Fprintf(out, "%s: %s: %s\n", query.Filename, query.Name, name)
 				} else {
-					fmt.Fprintf(c.Stderr, "%s: %s: %s: %s\n", query.Filename, query.Name, name, rule.Message)
+					fmt.Fprintf(out, "%s: %s: %s: %s\n", query.Filename, query.Name, name, rule.Message)
 				}
-				errored = true
+				failed = true
 			}
 		}
+		return failed, nil
+	}
+
+	jobs := c.Jobs
+	if jobs < 1 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	if jobs > len(req.Queries) {
+		jobs = len(req.Queries)
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	type queryResult struct {
+		output string
+		failed bool
+	}
+	results, err := runConcurrent(ctx, len(req.Queries), jobs, func(ctx context.Context, i, worker int) (queryResult, error) {
+		var buf strings.Builder
+		failed, err := checkQuery(i, worker, &buf)
+		if err != nil {
+			return queryResult{}, err
+		}
+		return queryResult{output: buf.String(), failed: failed}, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	errored := false
+	for _, r := range results {
+		if r.output != "" {
+			fmt.Fprint(c.Stderr, r.output)
+		}
+		if r.failed {
+			errored = true
+		}
 	}
 	if errored {
 		return ErrFailedChecks
@@ -455,6 +957,71 @@ func (c *checker) checkSQL(ctx context.Context, s config.SQL) error {
 	return nil
 }
 
+// runConcurrent runs work for every index in [0, n) across up to jobs
+// goroutines and returns the results in their original index order,
+// regardless of completion order. If any call to work returns an error,
+// runConcurrent stops dispatching new work, waits for in-flight calls to
+// finish, and returns that error instead of a result slice.
+func runConcurrent[T any](ctx context.Context, n, jobs int, work func(ctx context.Context, i, worker int) (T, error)) ([]T, error) {
+	if jobs < 1 {
+		jobs = 1
+	}
+	if jobs > n {
+		jobs = n
+	}
+	results := make([]T, n)
+	if n == 0 {
+		return results, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	queue := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := range queue {
+				v, err := work(ctx, i, worker)
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+					mu.Unlock()
+					continue
+				}
+				results[i] = v
+			}
+		}(w)
+	}
+feed:
+	for i := 0; i < n; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case queue <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
+
 func vetConfig(req *plugin.CodeGenRequest) *plugin.VetConfig {
 	return &plugin.VetConfig{
 		Version: req.Settings.Version,
@@ -482,4 +1049,304 @@ func vetQuery(q *plugin.Query) *plugin.VetQuery {
 type vetEngineOutput struct {
 	PostgreSQL *plugin.PostgreSQL
 	MySQL      *plugin.MySQL
+	MSSQL      *plugin.MSSQL
+}
+
+// schemaStatements returns the SQL statements that should be run to bring a
+// managed database up to date with s. If s.Database.Migrations is set, the
+// *.sql files in that directory are applied in lexical order (the
+// goose/golang-migrate convention); otherwise the files listed in s.Schema
+// are used, in the order they were configured.
+func schemaStatements(s config.SQL) ([]string, error) {
+	files := s.Schema
+	if s.Database != nil && s.Database.Migrations != "" {
+		entries, err := os.ReadDir(s.Database.Migrations)
+		if err != nil {
+			return nil, fmt.Errorf("database: reading migrations directory: %w", err)
+		}
+		files = nil
+		for _, e := range entries {
+			name := e.Name()
+			if e.IsDir() || !strings.HasSuffix(name, ".sql") || strings.Contains(name, ".down.") {
+				continue
+			}
+			files = append(files, filepath.Join(s.Database.Migrations, name))
+		}
+		sort.Strings(files)
+	}
+
+	var stmts []string
+	for _, f := range files {
+		contents, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("database: reading %s: %w", f, err)
+		}
+		// TODO: This naive split doesn't understand strings, comments, or
+		// dollar-quoted bodies; it's good enough for the plain DDL most
+		// schema and migration files contain.
+		for _, stmt := range strings.Split(string(contents), ";") {
+			if s := strings.TrimSpace(stmt); s != "" {
+				stmts = append(stmts, s)
+			}
+		}
+	}
+	return stmts, nil
+}
+
+// schemaFingerprint hashes s's effective schema or migration statements
+// (sorted for determinism, since schemaStatements' file order isn't
+// meaningful to the cache) so the vet cache can tell a schema or migration
+// edit apart from a database that simply hasn't changed, even when the
+// engine version is identical.
+func schemaFingerprint(s config.SQL) (string, error) {
+	stmts, err := schemaStatements(s)
+	if err != nil {
+		return "", err
+	}
+	sorted := append([]string(nil), stmts...)
+	sort.Strings(sorted)
+	h := sha256.New()
+	for _, stmt := range sorted {
+		io.WriteString(h, stmt)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// managePostgresSchema creates a throwaway schema, points search_path at it,
+// and applies s's schema or migrations to it. The returned cleanup func
+// drops the schema; callers should defer it immediately.
+func managePostgresSchema(ctx context.Context, conn *pgx.Conn, s config.SQL) (string, func(context.Context) error, error) {
+	stmts, err := schemaStatements(s)
+	if err != nil {
+		return "", nil, err
+	}
+
+	schemaName := fmt.Sprintf("sqlc_vet_%d", time.Now().UnixNano())
+	if _, err := conn.Exec(ctx, fmt.Sprintf("CREATE SCHEMA %s", schemaName)); err != nil {
+		return "", nil, fmt.Errorf("database: creating managed schema: %w", err)
+	}
+	cleanup := func(ctx context.Context) error {
+		_, err := conn.Exec(ctx, fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", schemaName))
+		return err
+	}
+
+	// Keep "public" on the search_path behind the managed schema so
+	// extensions installed there (pg_stat_statements, in particular) are
+	// still visible to stats.* rules.
+	if _, err := conn.Exec(ctx, fmt.Sprintf("SET search_path TO %s, public", schemaName)); err != nil {
+		cleanup(ctx)
+		return "", nil, fmt.Errorf("database: setting search_path: %w", err)
+	}
+	for _, stmt := range stmts {
+		if _, err := conn.Exec(ctx, stmt); err != nil {
+			cleanup(ctx)
+			return "", nil, fmt.Errorf("database: applying managed schema: %w", err)
+		}
+	}
+	return schemaName, cleanup, nil
+}
+
+// manageMySQLDatabase creates a throwaway database on the server addressed
+// by dburl and returns a DSN pointing at it, along with a cleanup func that
+// drops it. The schema is applied by the caller via applySchemaDB once it
+// has reconnected using the returned DSN.
+func manageMySQLDatabase(ctx context.Context, dburl string, s config.SQL) (string, func(context.Context) error, error) {
+	admin, err := sql.Open("mysql", dburl)
+	if err != nil {
+		return "", nil, fmt.Errorf("database: connection error: %s", err)
+	}
+
+	dbName := fmt.Sprintf("sqlc_vet_%d", time.Now().UnixNano())
+	if _, err := admin.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE %s", dbName)); err != nil {
+		admin.Close()
+		return "", nil, fmt.Errorf("database: creating managed database: %w", err)
+	}
+	// admin stays open until cleanup runs, so cleanup can still reach the
+	// server to drop the database.
+	cleanup := func(ctx context.Context) error {
+		defer admin.Close()
+		_, err := admin.ExecContext(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS %s", dbName))
+		return err
+	}
+
+	base, _, _ := strings.Cut(dburl, "?")
+	base = base[:strings.LastIndex(base, "/")+1]
+	return base + dbName, cleanup, nil
+}
+
+// manageSQLiteFile creates a throwaway SQLite database file and returns a
+// DSN pointing at it, along with a cleanup func that removes it.
+func manageSQLiteFile() (string, func(), error) {
+	f, err := os.CreateTemp("", "sqlc_vet_*.db")
+	if err != nil {
+		return "", nil, fmt.Errorf("database: creating managed database file: %w", err)
+	}
+	f.Close()
+	return f.Name(), func() { os.Remove(f.Name()) }, nil
+}
+
+// applySchemaDB applies s's schema or migrations over a *sql.DB connection,
+// used by the MySQL and SQLite managed-database modes.
+func applySchemaDB(ctx context.Context, db *sql.DB, s config.SQL) error {
+	stmts, err := schemaStatements(s)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("database: applying managed schema: %w", err)
+		}
+	}
+	return nil
+}
+
+// vetCacheEntry is the on-disk representation of a single cached EXPLAIN
+// result, stored at .sqlc/vet-cache/<engine>/<sha256(query)>.json.
+type vetCacheEntry struct {
+	Query         string `json:"query"`
+	Engine        string `json:"engine"`
+	EngineVersion string `json:"engine_version,omitempty"`
+	// SchemaFingerprint is schemaFingerprint's hash of the schema or
+	// migration files in effect when this entry was recorded, so an edit
+	// to the schema invalidates the entry even when EngineVersion hasn't
+	// changed.
+	SchemaFingerprint string          `json:"schema_fingerprint,omitempty"`
+	Plan              json.RawMessage `json:"plan"`
+}
+
+// versionedExplainer is implemented by explainers that can report the live
+// database's version string, so the vet cache can detect entries recorded
+// against a database that has since been upgraded. Schema changes are
+// detected separately, by the schema fingerprint.
+type versionedExplainer interface {
+	EngineVersion(context.Context) (string, error)
+}
+
+// cachingExplainer wraps another explainer with an on-disk, hash-keyed
+// fixture cache so `sqlc vet` can run in CI without a live EXPLAIN
+// connection. See the `--record` and `--offline` vet flags.
+type cachingExplainer struct {
+	next   explainer // nil when running fully offline
+	engine string
+	dir    string
+	record bool
+
+	// engineVersion is the live database's version string, used to
+	// invalidate cache entries recorded against a different database.
+	// It's empty when there's no live connection to ask (pure --offline),
+	// in which case a change of engine version can't be detected.
+	engineVersion string
+
+	// schemaFingerprint is schemaFingerprint's hash of the schema or
+	// migration files being vetted against, used to invalidate cache
+	// entries recorded against an older version of the schema.
+	schemaFingerprint string
+}
+
+func (ce *cachingExplainer) path(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return filepath.Join(ce.dir, ce.engine, hex.EncodeToString(sum[:])+".json")
+}
+
+func (ce *cachingExplainer) Explain(ctx context.Context, query string, args ...*plugin.Parameter) (*vetEngineOutput, error) {
+	path := ce.path(query)
+
+	if !ce.record {
+		raw, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			var entry vetCacheEntry
+			if err := json.Unmarshal(raw, &entry); err != nil {
+				return nil, fmt.Errorf("vet cache: decoding %s: %w", path, err)
+			}
+			var staleBecause []string
+			if ce.engineVersion != "" && entry.EngineVersion != "" && entry.EngineVersion != ce.engineVersion {
+				staleBecause = append(staleBecause, fmt.Sprintf("recorded against engine version %q, database is now %q", entry.EngineVersion, ce.engineVersion))
+			}
+			if ce.schemaFingerprint != "" && entry.SchemaFingerprint != "" && entry.SchemaFingerprint != ce.schemaFingerprint {
+				staleBecause = append(staleBecause, "schema or migrations have changed since this entry was recorded")
+			}
+			if len(staleBecause) == 0 {
+				return unmarshalExplainOutput(ce.engine, entry.Plan)
+			}
+			if ce.next == nil {
+				return nil, fmt.Errorf("vet cache: %s is stale (%s); re-run with --record", path, strings.Join(staleBecause, "; "))
+			}
+			// fall through and refresh the stale entry from the live database
+		case !os.IsNotExist(err):
+			return nil, fmt.Errorf("vet cache: reading %s: %w", path, err)
+		case ce.next == nil:
+			return nil, fmt.Errorf("vet cache: no cache entry for query and no database connection; run with --record first")
+		}
+	}
+
+	out, err := ce.next.Explain(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := marshalExplainOutput(ce.engine, out)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.MarshalIndent(vetCacheEntry{
+		Query:             query,
+		Engine:            ce.engine,
+		EngineVersion:     ce.engineVersion,
+		SchemaFingerprint: ce.schemaFingerprint,
+		Plan:              plan,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("vet cache: creating %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		return nil, fmt.Errorf("vet cache: writing %s: %w", path, err)
+	}
+	return out, nil
+}
+
+// marshalExplainOutput extracts the single populated explain plan from out
+// and renders it as protojson, so it can be replayed later by
+// unmarshalExplainOutput. For PostgreSQL and MySQL this is the same JSON
+// shape the live explainers parse; the live MSSQL explainer parses XML.
+func marshalExplainOutput(engine string, out *vetEngineOutput) (json.RawMessage, error) {
+	switch config.Engine(engine) {
+	case config.EnginePostgreSQL:
+		return protojson.Marshal(out.PostgreSQL.Explain)
+	case config.EngineMySQL:
+		return protojson.Marshal(out.MySQL.Explain)
+	case config.EngineMSSQL:
+		return protojson.Marshal(out.MSSQL.Explain)
+	default:
+		return nil, fmt.Errorf("vet cache: unsupported engine: %s", engine)
+	}
+}
+
+func unmarshalExplainOutput(engine string, plan json.RawMessage) (*vetEngineOutput, error) {
+	switch config.Engine(engine) {
+	case config.EnginePostgreSQL:
+		var explain plugin.PostgreSQLExplain
+		if err := pjson.Unmarshal(plan, &explain); err != nil {
+			return nil, err
+		}
+		return &vetEngineOutput{PostgreSQL: &plugin.PostgreSQL{Explain: &explain}}, nil
+	case config.EngineMySQL:
+		var explain plugin.MySQLExplain
+		if err := pjson.Unmarshal(plan, &explain); err != nil {
+			return nil, err
+		}
+		return &vetEngineOutput{MySQL: &plugin.MySQL{Explain: &explain}}, nil
+	case config.EngineMSSQL:
+		var explain plugin.MSSQLExplain
+		if err := pjson.Unmarshal(plan, &explain); err != nil {
+			return nil, err
+		}
+		return &vetEngineOutput{MSSQL: &plugin.MSSQL{Explain: &explain}}, nil
+	default:
+		return nil, fmt.Errorf("vet cache: unsupported engine: %s", engine)
+	}
 }
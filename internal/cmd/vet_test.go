@@ -0,0 +1,330 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kyleconroy/sqlc/internal/config"
+	"github.com/kyleconroy/sqlc/internal/plugin"
+	"github.com/kyleconroy/sqlc/internal/sql/ast"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "collapses whitespace",
+			query: "SELECT  *\nFROM   foo",
+			want:  "select * from foo",
+		},
+		{
+			name:  "folds string literals",
+			query: "SELECT * FROM foo WHERE status = 'active'",
+			want:  "select * from foo where status = ?",
+		},
+		{
+			name:  "folds number literals",
+			query: "SELECT * FROM foo LIMIT 10",
+			want:  "select * from foo limit ?",
+		},
+		{
+			name:  "folds doubled quotes inside a literal",
+			query: "SELECT 'it''s here' FROM foo",
+			want:  "select ? from foo",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeQuery(tt.query); got != tt.want {
+				t.Errorf("normalizeQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrepareable(t *testing.T) {
+	tests := []struct {
+		name   string
+		engine config.Engine
+		stmt   ast.Node
+		want   bool
+	}{
+		{"postgres select", config.EnginePostgreSQL, &ast.SelectStmt{}, true},
+		{"postgres insert", config.EnginePostgreSQL, &ast.InsertStmt{}, true},
+		{"postgres update", config.EnginePostgreSQL, &ast.UpdateStmt{}, true},
+		{"postgres delete", config.EnginePostgreSQL, &ast.DeleteStmt{}, true},
+		{"postgres other", config.EnginePostgreSQL, &ast.TruncateStmt{}, false},
+		{"mysql always preparable", config.EngineMySQL, &ast.TruncateStmt{}, true},
+		{"sqlite always preparable", config.EngineSQLite, &ast.TruncateStmt{}, true},
+		{"mssql always preparable", config.EngineMSSQL, &ast.TruncateStmt{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql := config.SQL{Engine: tt.engine}
+			raw := &ast.RawStmt{Stmt: tt.stmt}
+			if got := prepareable(sql, raw); got != tt.want {
+				t.Errorf("prepareable(%s, %T) = %v, want %v", tt.engine, tt.stmt, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRunConcurrentPreservesOrder guards the worker pool's core promise:
+// results come back in submission order even though workers finish in a
+// different order. Item 0 is made the slowest so a naive "append as each
+// worker finishes" implementation would reorder it.
+func TestRunConcurrentPreservesOrder(t *testing.T) {
+	const n = 8
+	results, err := runConcurrent(context.Background(), n, 4, func(ctx context.Context, i, worker int) (int, error) {
+		time.Sleep(time.Duration(n-i) * time.Millisecond)
+		return i * i, nil
+	})
+	if err != nil {
+		t.Fatalf("runConcurrent returned error: %v", err)
+	}
+	for i, v := range results {
+		if v != i*i {
+			t.Errorf("results[%d] = %d, want %d", i, v, i*i)
+		}
+	}
+}
+
+func TestRunConcurrentPropagatesFirstError(t *testing.T) {
+	boom := errors.New("boom")
+	var ran int32
+	_, err := runConcurrent(context.Background(), 20, 4, func(ctx context.Context, i, worker int) (int, error) {
+		atomic.AddInt32(&ran, 1)
+		if i == 5 {
+			return 0, boom
+		}
+		// give the cancellation a chance to stop work that hasn't started yet
+		time.Sleep(time.Millisecond)
+		return i, nil
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("runConcurrent error = %v, want %v", err, boom)
+	}
+	if atomic.LoadInt32(&ran) > 20 {
+		t.Fatalf("ran %d items, want at most 20", ran)
+	}
+}
+
+func TestRunConcurrentEmpty(t *testing.T) {
+	results, err := runConcurrent(context.Background(), 0, 4, func(ctx context.Context, i, worker int) (int, error) {
+		t.Fatal("work should not be called for n == 0")
+		return 0, nil
+	})
+	if err != nil {
+		t.Fatalf("runConcurrent returned error: %v", err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("len(results) = %d, want 0", len(results))
+	}
+}
+
+// stubExplainer is a test double for explainer; it records how many times
+// it was called so tests can tell a cache hit from a refresh.
+type stubExplainer struct {
+	mu    sync.Mutex
+	out   *vetEngineOutput
+	err   error
+	calls int
+}
+
+func (s *stubExplainer) Explain(ctx context.Context, query string, args ...*plugin.Parameter) (*vetEngineOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	return s.out, s.err
+}
+
+func (s *stubExplainer) callCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func TestCachingExplainerRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	live := &stubExplainer{out: &vetEngineOutput{PostgreSQL: &plugin.PostgreSQL{Explain: &plugin.PostgreSQLExplain{}}}}
+
+	recorder := &cachingExplainer{next: live, engine: string(config.EnginePostgreSQL), dir: dir, record: true, engineVersion: "pg-1"}
+	if _, err := recorder.Explain(context.Background(), "SELECT 1"); err != nil {
+		t.Fatalf("record Explain: %v", err)
+	}
+	if live.callCount() != 1 {
+		t.Fatalf("live.calls = %d, want 1 after recording", live.callCount())
+	}
+
+	path := recorder.path("SELECT 1")
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading recorded cache entry: %v", err)
+	}
+	var entry vetCacheEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		t.Fatalf("decoding recorded cache entry: %v", err)
+	}
+	if entry.EngineVersion != "pg-1" {
+		t.Fatalf("entry.EngineVersion = %q, want %q", entry.EngineVersion, "pg-1")
+	}
+
+	// Replaying offline against the same engine version must not touch the
+	// live explainer at all.
+	offline := &cachingExplainer{next: nil, engine: string(config.EnginePostgreSQL), dir: dir, record: false, engineVersion: "pg-1"}
+	if _, err := offline.Explain(context.Background(), "SELECT 1"); err != nil {
+		t.Fatalf("offline replay Explain: %v", err)
+	}
+	if live.callCount() != 1 {
+		t.Fatalf("live.calls = %d after offline replay, want still 1", live.callCount())
+	}
+}
+
+func TestCachingExplainerStaleEntryIsRefreshed(t *testing.T) {
+	dir := t.TempDir()
+	path := (&cachingExplainer{engine: string(config.EnginePostgreSQL), dir: dir}).path("SELECT 1")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	stale := vetCacheEntry{Query: "SELECT 1", Engine: string(config.EnginePostgreSQL), EngineVersion: "pg-1", Plan: json.RawMessage("{}")}
+	raw, err := json.Marshal(stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	live := &stubExplainer{out: &vetEngineOutput{PostgreSQL: &plugin.PostgreSQL{Explain: &plugin.PostgreSQLExplain{}}}}
+	ce := &cachingExplainer{next: live, engine: string(config.EnginePostgreSQL), dir: dir, record: false, engineVersion: "pg-2"}
+	if _, err := ce.Explain(context.Background(), "SELECT 1"); err != nil {
+		t.Fatalf("Explain with stale entry: %v", err)
+	}
+	if live.callCount() != 1 {
+		t.Fatalf("live.calls = %d, want 1 when a stale entry falls through to the database", live.callCount())
+	}
+
+	// Without a live database to refresh from, a stale entry must fail
+	// loudly instead of silently replaying outdated data. The refresh above
+	// rewrote the entry, so put the stale one back first.
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	offline := &cachingExplainer{next: nil, engine: string(config.EnginePostgreSQL), dir: dir, record: false, engineVersion: "pg-2"}
+	if _, err := offline.Explain(context.Background(), "SELECT 1"); err == nil {
+		t.Fatal("Explain with stale entry and no database connection: want error, got nil")
+	}
+}
+
+func TestCachingExplainerStaleSchemaIsRefreshed(t *testing.T) {
+	dir := t.TempDir()
+	path := (&cachingExplainer{engine: string(config.EnginePostgreSQL), dir: dir}).path("SELECT 1")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	stale := vetCacheEntry{
+		Query:             "SELECT 1",
+		Engine:            string(config.EnginePostgreSQL),
+		EngineVersion:     "pg-1",
+		SchemaFingerprint: "old-schema",
+		Plan:              json.RawMessage("{}"),
+	}
+	raw, err := json.Marshal(stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Engine version hasn't changed, but the schema fingerprint has -- a
+	// schema edit must still invalidate the entry even though a version
+	// bump didn't happen.
+	live := &stubExplainer{out: &vetEngineOutput{PostgreSQL: &plugin.PostgreSQL{Explain: &plugin.PostgreSQLExplain{}}}}
+	ce := &cachingExplainer{next: live, engine: string(config.EnginePostgreSQL), dir: dir, record: false, engineVersion: "pg-1", schemaFingerprint: "new-schema"}
+	if _, err := ce.Explain(context.Background(), "SELECT 1"); err != nil {
+		t.Fatalf("Explain with stale schema fingerprint: %v", err)
+	}
+	if live.callCount() != 1 {
+		t.Fatalf("live.calls = %d, want 1 when the schema fingerprint changed", live.callCount())
+	}
+
+	// The refresh above rewrote the entry, so put the stale one back before
+	// checking the offline case.
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	offline := &cachingExplainer{next: nil, engine: string(config.EnginePostgreSQL), dir: dir, record: false, engineVersion: "pg-1", schemaFingerprint: "new-schema"}
+	if _, err := offline.Explain(context.Background(), "SELECT 1"); err == nil {
+		t.Fatal("Explain with stale schema fingerprint and no database connection: want error, got nil")
+	}
+}
+
+func TestSchemaFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	write := func(contents string) {
+		if err := os.WriteFile(filepath.Join(dir, "schema.sql"), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("CREATE TABLE foo (id int);")
+	sql := config.SQL{Schema: []string{filepath.Join(dir, "schema.sql")}}
+	original, err := schemaFingerprint(sql)
+	if err != nil {
+		t.Fatalf("schemaFingerprint: %v", err)
+	}
+	again, err := schemaFingerprint(sql)
+	if err != nil {
+		t.Fatalf("schemaFingerprint: %v", err)
+	}
+	if original != again {
+		t.Errorf("schemaFingerprint is not deterministic: %q != %q", original, again)
+	}
+
+	// Changing a column's type changes the real EXPLAIN plan, so it must
+	// also change the fingerprint.
+	write("CREATE TABLE foo (id text);")
+	changed, err := schemaFingerprint(sql)
+	if err != nil {
+		t.Fatalf("schemaFingerprint: %v", err)
+	}
+	if changed == original {
+		t.Error("schemaFingerprint did not change after editing the schema file")
+	}
+}
+
+func TestSchemaStatementsMigrations(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"0002_add_bar.sql":         "ALTER TABLE foo ADD bar int;",
+		"0001_create_foo.sql":      "CREATE TABLE foo (id int);",
+		"0001_create_foo.down.sql": "DROP TABLE foo;",
+		"README.md":                "not a migration",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stmts, err := schemaStatements(config.SQL{Database: &config.Database{Migrations: dir}})
+	if err != nil {
+		t.Fatalf("schemaStatements: %v", err)
+	}
+	want := []string{"CREATE TABLE foo (id int)", "ALTER TABLE foo ADD bar int"}
+	if fmt.Sprint(stmts) != fmt.Sprint(want) {
+		t.Errorf("schemaStatements = %v, want %v (down migrations and non-.sql files must be excluded, and order must be lexical)", stmts, want)
+	}
+}